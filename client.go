@@ -2,20 +2,15 @@ package main
 
 import (
 	"bytes"
-	"context"
-	"crypto/tls"
 	"errors"
 	"fmt"
 	"image"
 	"image/color"
 	"log"
 	"os"
-	"strconv"
 	"strings"
 	"time"
 
-	"google.golang.org/grpc/credentials"
-
 	_ "embed"
 	_ "image/png"
 
@@ -26,17 +21,14 @@ import (
 	"github.com/hajimehoshi/ebiten/v2/inpututil"
 	"github.com/hajimehoshi/ebiten/v2/text"
 
-	pb "github.com/WalisNw/clock-in-out-client/proto"
+	"google.golang.org/grpc/connectivity"
 
-	"google.golang.org/grpc"
+	"github.com/WalisNw/clock-in-out-client/internal/app"
+	pb "github.com/WalisNw/clock-in-out-client/proto"
 )
 
 var (
-	_host     string
-	_port     string
-	_insecure string
-	_version  string
-	_id       string
+	_version string
 )
 
 const (
@@ -45,9 +37,6 @@ const (
 	Padding            = 18
 	Row                = 24
 	RegularTermination = "terminate"
-	AlertInterval      = 480
-	CountDownInterval  = 300
-	DateTimeLayout = "2006/01/02 15:04:05"
 )
 
 var (
@@ -64,27 +53,6 @@ var (
 	uncheckedImage *ebiten.Image
 )
 
-type Flag uint16
-
-const (
-	Connecting Flag = 1 << iota
-	Loading
-	Select
-	Clock
-	Record
-	Clocking
-	Querying
-	CountDown
-	Result
-)
-
-type Func uint8
-
-const (
-	FuncClock Func = 1 << iota
-	FuncQuery
-)
-
 func init() {
 	tt, err := opentype.Parse(ttf)
 	if err != nil {
@@ -125,150 +93,65 @@ func repeatingKeyPressed(key ebiten.Key) bool {
 	return false
 }
 
+// Game is the Ebiten frontend. It only translates key presses into calls
+// on app.App and renders the app's exported state; the clock-in/out flow
+// itself lives in internal/app so the TUI frontend can share it.
 type Game struct {
-	gRPC      *gRPC
-	counter   uint16
-	flag      Flag
-	funcFlag  Func
-	tick      uint
-	alert     string
-	msg       string
-	clockType pb.ClockType
-	queryType pb.QueryType
-	records   []*pb.Record
+	app *app.App
 }
 
 func (g *Game) Update() error {
-	switch g.flag {
-	case Connecting:
-		g.flag = Loading
-		go func() {
-			fmt.Println("connecting...")
-			ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-			defer cancel()
-			var (
-				opts []grpc.DialOption
-			)
-			if _insecure != "" {
-				opts = append(opts, grpc.WithInsecure())
-			} else {
-				opts = append(opts, grpc.WithAuthority(_host))
-				cred := credentials.NewTLS(&tls.Config{
-					InsecureSkipVerify: false,
-				})
-				opts = append(opts, grpc.WithTransportCredentials(cred))
-			}
-			opts = append(opts, grpc.WithBlock())
-			conn, err := grpc.DialContext(ctx, fmt.Sprintf("%s:%s", _host, _port), opts...)
-			if err != nil {
-				log.Printf("Failed to connect. err: %v", err)
-				g.alert = "連線異常"
-				g.tick = AlertInterval
-				g.flag = Connecting
-				return
-			}
-			g.gRPC.conn = conn
-			g.gRPC.client = pb.NewClockServiceClient(conn)
-			g.flag = Select
-			g.funcFlag = FuncClock
-		}()
-	case Loading:
-		g.msg = "連線中"
-	case Select:
+	var err error
+	switch g.app.View().State {
+	case app.Select, app.Clock, app.Record, app.DatePicker:
 		switch {
 		case repeatingKeyPressed(ebiten.KeyEnter), repeatingKeyPressed(ebiten.KeyArrowRight):
-			switch g.funcFlag {
-			case FuncClock:
-				g.flag = Clock
-			case FuncQuery:
-				g.flag = Record
-			}
+			err = g.app.Confirm()
 		case repeatingKeyPressed(ebiten.KeyArrowUp):
-			g.funcFlag = FuncClock
+			g.app.Up()
 		case repeatingKeyPressed(ebiten.KeyArrowDown):
-			g.funcFlag = FuncQuery
-		}
-	case Clock:
-		switch {
-		case repeatingKeyPressed(ebiten.KeyEnter), repeatingKeyPressed(ebiten.KeyArrowRight):
-			g.flag = Clocking
-			go func() {
-				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-				defer cancel()
-				id, _ := strconv.Atoi(_id)
-				res, err := g.gRPC.client.Clock(ctx, &pb.ClockRequest{Member: &pb.Member{Id: int32(id)}, Type: g.clockType})
-				if err != nil {
-					log.Printf("Failed to clock in/out. err: %v", err)
-					g.alert = "打卡失敗"
-					g.tick = AlertInterval
-					g.flag = Select
-					return
-				}
-				g.msg = fmt.Sprintf("%v %v", res.Result, res.Time.AsTime().Local().Format(DateTimeLayout))
-				g.flag = CountDown
-				g.tick = CountDownInterval
-			}()
-		case repeatingKeyPressed(ebiten.KeyArrowUp):
-			g.clockType = pb.ClockType_CLOCK_IN
-		case repeatingKeyPressed(ebiten.KeyArrowDown):
-			g.clockType = pb.ClockType_CLOCK_OUT
+			g.app.Down()
 		case repeatingKeyPressed(ebiten.KeyArrowLeft):
-			g.flag = Select
+			g.app.Back()
+		}
+	case app.Queued, app.CountDown:
+		if repeatingKeyPressed(ebiten.KeyEnter) {
+			err = g.app.Confirm()
 		}
-	case Record:
+	case app.Result:
 		switch {
-		case repeatingKeyPressed(ebiten.KeyEnter), repeatingKeyPressed(ebiten.KeyArrowRight):
-			g.flag = Querying
-			go func() {
-				ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
-				defer cancel()
-				id, _ := strconv.Atoi(_id)
-				res, err := g.gRPC.client.Query(ctx, &pb.QueryRequest{Member: &pb.Member{Id: int32(id)}, Type: g.queryType})
-				if err != nil {
-					log.Printf("Failed to query records. err: %v", err)
-					g.alert = "查詢失敗"
-					g.tick = AlertInterval
-					g.flag = Select
-					return
-				}
-				g.records = res.Records
-				g.flag = Result
-			}()
+		case repeatingKeyPressed(ebiten.KeyArrowLeft):
+			g.app.Back()
 		case repeatingKeyPressed(ebiten.KeyArrowUp):
-			g.queryType = pb.QueryType_DAY
+			g.app.Scroll(-1)
 		case repeatingKeyPressed(ebiten.KeyArrowDown):
-			g.queryType = pb.QueryType_LAST_SEVEN
-		case repeatingKeyPressed(ebiten.KeyArrowLeft):
-			g.flag = Select
-		}
-	case Clocking, Querying:
-		g.msg = "請稍候"
-	case CountDown:
-		if repeatingKeyPressed(ebiten.KeyEnter) || g.tick == 0 {
-			fmt.Println("Shutdown!")
-			return errors.New(RegularTermination)
-		}
-	case Result:
-		if repeatingKeyPressed(ebiten.KeyArrowLeft) {
-			g.flag = Record
+			g.app.Scroll(1)
+		case repeatingKeyPressed(ebiten.KeyPageUp):
+			g.app.Scroll(-resultVisibleRows())
+		case repeatingKeyPressed(ebiten.KeyPageDown):
+			g.app.Scroll(resultVisibleRows())
 		}
 	}
-	g.counter++
-	g.tick--
-	if g.tick == 0 {
-		g.alert = ""
+	if err == nil {
+		err = g.app.Advance()
+	}
+	if errors.Is(err, app.ErrShutdown) {
+		fmt.Println("Shutdown!")
+		return errors.New(RegularTermination)
 	}
-	return nil
+	return err
 }
 
 func (g *Game) Draw(screen *ebiten.Image) {
-	text.Draw(screen, fmt.Sprintf("現在時間: %s", time.Now().Format(DateTimeLayout)), regularFont, Padding, Padding+Row, color.White)
-	text.Draw(screen, g.alert, regularFont, Padding, Padding+Row*2, color.RGBA64{R: 0xffff, A: 0xff00})
-	switch g.flag {
-	case Select:
+	a := g.app.View()
+	text.Draw(screen, fmt.Sprintf("現在時間: %s", time.Now().Format(app.DateTimeLayout)), regularFont, Padding, Padding+Row, color.White)
+	text.Draw(screen, "●", regularFont, ScreenWidth-Padding-14, Padding+Row, connStateColor(a.ConnState))
+	text.Draw(screen, a.Alert, regularFont, Padding, Padding+Row*2, color.RGBA64{R: 0xffff, A: 0xff00})
+	switch a.State {
+	case app.Select:
 		text.Draw(screen, "請選擇:", regularFont, Padding, Padding+Row*5, color.White)
 		op := &ebiten.DrawImageOptions{}
-		if g.funcFlag == FuncClock {
+		if a.FuncFlag == app.FuncClock {
 			op.GeoM.Translate(Padding, Row*7+2)
 			screen.DrawImage(checkedImage, op)
 			op.GeoM.Translate(0, Row)
@@ -281,10 +164,10 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		}
 		text.Draw(screen, "打卡", regularFont, Padding+20, Padding+Row*7, color.White)
 		text.Draw(screen, "查詢", regularFont, Padding+20, Padding+Row*8, color.White)
-	case Clock:
+	case app.Clock:
 		text.Draw(screen, "請選擇:", regularFont, Padding, Padding+Row*5, color.White)
 		op := &ebiten.DrawImageOptions{}
-		if g.clockType == pb.ClockType_CLOCK_IN {
+		if a.ClockType == pb.ClockType_CLOCK_IN {
 			op.GeoM.Translate(Padding, Row*7+2)
 			screen.DrawImage(checkedImage, op)
 			op.GeoM.Translate(0, Row)
@@ -297,68 +180,93 @@ func (g *Game) Draw(screen *ebiten.Image) {
 		}
 		text.Draw(screen, "上班打卡", regularFont, Padding+20, Padding+Row*7, color.White)
 		text.Draw(screen, "下班打卡", regularFont, Padding+20, Padding+Row*8, color.White)
-	case Record:
+	case app.Record:
 		text.Draw(screen, "請選擇:", regularFont, Padding, Padding+Row*5, color.White)
-		op := &ebiten.DrawImageOptions{}
-		if g.queryType == pb.QueryType_DAY {
-			op.GeoM.Translate(Padding, Row*7+2)
-			screen.DrawImage(checkedImage, op)
-			op.GeoM.Translate(0, Row)
-			screen.DrawImage(uncheckedImage, op)
-		} else {
-			op.GeoM.Translate(Padding, Row*7+2)
-			screen.DrawImage(uncheckedImage, op)
-			op.GeoM.Translate(0, Row)
-			screen.DrawImage(checkedImage, op)
+		drawRadio := func(row int, label string, selected bool) {
+			img := uncheckedImage
+			if selected {
+				img = checkedImage
+			}
+			op := &ebiten.DrawImageOptions{}
+			op.GeoM.Translate(Padding, float64(Row*row+2))
+			screen.DrawImage(img, op)
+			text.Draw(screen, label, regularFont, Padding+20, Padding+Row*row, color.White)
 		}
-		text.Draw(screen, "本日", regularFont, Padding+20, Padding+Row*7, color.White)
-		text.Draw(screen, "前七日", regularFont, Padding+20, Padding+Row*8, color.White)
+		drawRadio(7, "本日", a.QueryType == pb.QueryType_DAY)
+		drawRadio(8, "前七日", a.QueryType == pb.QueryType_LAST_SEVEN)
+		drawRadio(9, "自訂區間", a.QueryType == pb.QueryType_RANGE)
+	case app.DatePicker:
+		text.Draw(screen, "請選擇查詢區間:", regularFont, Padding, Padding+Row*5, color.White)
+		text.Draw(screen, fmt.Sprintf("最近 %d 天 (%s ~ %s)", a.RangeDays, a.RangeStart().Format("2006/01/02"), time.Now().Format("2006/01/02")), regularFont, Padding, Padding+Row*6, color.White)
+		text.Draw(screen, "↑/↓ 調整天數，Enter 查詢，← 返回", regularFont, Padding, Padding+Row*7, color.White)
 
-	case Loading, Clocking, Querying:
-		msg := g.msg
-		msg += strings.Repeat(".", int(g.counter)%180/30)
+	case app.Loading, app.Clocking, app.Querying:
+		msg := a.Msg
+		msg += strings.Repeat(".", int(a.Counter)%180/30)
+		text.Draw(screen, msg, regularFont, Padding, Padding+Row*5, color.White)
+	case app.Queued:
+		msg := "已記錄，待同步"
+		msg += strings.Repeat(".", int(a.Counter)%180/30)
 		text.Draw(screen, msg, regularFont, Padding, Padding+Row*5, color.White)
-	case CountDown:
-		text.Draw(screen, g.msg, regularFont, Padding, Padding+Row*5, color.White)
-		text.Draw(screen, fmt.Sprintf("將於 %d 秒後自動關閉或按<Enter>直接關閉", (g.tick/60)+1), regularFont, Padding, Padding+Row*7, color.White)
-	case Result:
+		if a.QueuePending > 1 {
+			text.Draw(screen, fmt.Sprintf("尚有 %d 筆等待同步", a.QueuePending), regularFont, Padding, Padding+Row*6, color.White)
+		}
+	case app.CountDown:
+		text.Draw(screen, a.Msg, regularFont, Padding, Padding+Row*5, color.White)
+		text.Draw(screen, fmt.Sprintf("將於 %d 秒後自動關閉或按<Enter>直接關閉", (a.Tick/60)+1), regularFont, Padding, Padding+Row*7, color.White)
+	case app.Result:
 		text.Draw(screen, "日期", regularFont, Padding, Padding+Row*3, color.White)
 		text.Draw(screen, "上班", regularFont, 200, Padding+Row*3, color.White)
 		text.Draw(screen, "下班", regularFont, 340, Padding+Row*3, color.White)
-		for i, r := range g.records {
+		visible := resultVisibleRows()
+		for i := 0; i < visible && a.ScrollOffset+i < len(a.Records); i++ {
+			r := a.Records[a.ScrollOffset+i]
 			text.Draw(screen, r.Date, regularFont, Padding, Padding+Row*(4+i), color.White)
 			text.Draw(screen, r.In, regularFont, 200, Padding+Row*(4+i), color.White)
 			text.Draw(screen, r.Out, regularFont, 340, Padding+Row*(4+i), color.White)
 		}
+		if a.Streaming {
+			text.Draw(screen, "載入中...", regularFont, Padding, ScreenHeight-Padding, color.White)
+		}
 	}
 }
 
-func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
-	return outsideWidth, outsideHeight
+// connStateColor maps a gRPC connectivity state to the connection-state dot
+// drawn next to the clock: green once Ready, yellow while Idle or
+// Connecting, red otherwise (TransientFailure, or Shutdown, which is also
+// the state before the first successful dial).
+func connStateColor(s connectivity.State) color.Color {
+	switch s {
+	case connectivity.Ready:
+		return color.RGBA{G: 0xc0, A: 0xff}
+	case connectivity.Idle, connectivity.Connecting:
+		return color.RGBA{R: 0xc0, G: 0xc0, A: 0xff}
+	default:
+		return color.RGBA{R: 0xc0, A: 0xff}
+	}
 }
 
-type gRPC struct {
-	conn   *grpc.ClientConn
-	client pb.ClockServiceClient
+// resultVisibleRows returns how many record rows fit between the first
+// record row drawn at Padding+Row*4 and the bottom padding.
+func resultVisibleRows() int {
+	return (ScreenHeight - Padding - (Padding + Row*4)) / Row
 }
 
-func (g *gRPC) close() {
-	if g.conn != nil {
-		fmt.Printf("client disconnected")
-		_ = g.conn.Close()
-	}
+func (g *Game) Layout(outsideWidth, outsideHeight int) (int, int) {
+	return outsideWidth, outsideHeight
 }
 
-func NewGame() (*Game, func()) {
-	g := &Game{gRPC: &gRPC{}, flag: Connecting}
-	return g, g.gRPC.close
+func NewGame(cfg *app.Config) (*Game, func()) {
+	a := app.New(*cfg)
+	return &Game{app: a}, a.Close
 }
 
-func main() {
+func runEbiten(cfg *app.Config) {
 	ebiten.SetWindowTitle(fmt.Sprintf("NW 打卡系統 - v%s", _version))
 	ebiten.SetWindowSize(ScreenWidth, ScreenHeight)
-	g, closeConn := NewGame()
-	defer closeConn()
+	ebiten.SetFullscreen(cfg.Fullscreen)
+	g, closeApp := NewGame(cfg)
+	defer closeApp()
 	if err := ebiten.RunGame(g); err != nil && err.Error() != RegularTermination {
 		log.Printf("an error occurred: %v", err)
 	}
@@ -0,0 +1,103 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/WalisNw/clock-in-out-client/internal/app"
+)
+
+// LoadConfig builds a Config from command-line flags, falling back to
+// CIO_* environment variables for defaults and, for anything still unset,
+// an optional YAML config file (-config, default $CIO_CONFIG or the user
+// config dir).
+func LoadConfig() (*app.Config, error) {
+	var (
+		host       = flag.String("host", app.EnvOr("CIO_HOST", ""), "clock server host")
+		port       = flag.String("port", app.EnvOr("CIO_PORT", ""), "clock server port (default 443)")
+		id         = flag.String("id", app.EnvOr("CIO_ID", ""), "member id punching this kiosk")
+		insecure   = flag.Bool("insecure", app.EnvOr("CIO_INSECURE", "") != "", "disable TLS")
+		fullscreen = flag.Bool("fullscreen", app.EnvOr("CIO_FULLSCREEN", "") != "", "run in fullscreen")
+		serverName = flag.String("server-name", app.EnvOr("CIO_SERVER_NAME", ""), "TLS SNI override for the clock server")
+		secret     = flag.String("secret", app.EnvOr("CIO_SECRET", ""), "shared secret used to sign requests with HMAC-SHA256; empty disables signing")
+		tui        = flag.Bool("tui", app.EnvOr("CIO_TUI", "") != "", "run as a terminal UI instead of the Ebiten window")
+		configPath = flag.String("config", app.EnvOr("CIO_CONFIG", defaultConfigPath()), "path to an optional YAML config file")
+	)
+	flag.Parse()
+
+	cfg := &app.Config{
+		Host:       *host,
+		Port:       *port,
+		ID:         *id,
+		Insecure:   *insecure,
+		Fullscreen: *fullscreen,
+		ServerName: *serverName,
+		Secret:     *secret,
+		TUI:        *tui,
+	}
+
+	if *configPath != "" {
+		if err := mergeConfigFile(cfg, *configPath); err != nil {
+			return nil, err
+		}
+	}
+	if cfg.Port == "" {
+		cfg.Port = "443"
+	}
+	return cfg, nil
+}
+
+// mergeConfigFile fills in any field still at its zero value from the YAML
+// file at path, so a value set by a flag or environment variable takes
+// priority over it; a boolean left false by them can still be turned on by
+// the file. A missing file is not an error.
+func mergeConfigFile(cfg *app.Config, path string) error {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("reading config file: %w", err)
+	}
+	var file app.Config
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("parsing config file %s: %w", path, err)
+	}
+	if cfg.Host == "" {
+		cfg.Host = file.Host
+	}
+	if cfg.Port == "" {
+		cfg.Port = file.Port
+	}
+	if cfg.ID == "" {
+		cfg.ID = file.ID
+	}
+	if !cfg.Insecure {
+		cfg.Insecure = file.Insecure
+	}
+	if !cfg.Fullscreen {
+		cfg.Fullscreen = file.Fullscreen
+	}
+	if cfg.ServerName == "" {
+		cfg.ServerName = file.ServerName
+	}
+	if cfg.Secret == "" {
+		cfg.Secret = file.Secret
+	}
+	return nil
+}
+
+func defaultConfigPath() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "clock-in-out-client", "config.yaml")
+}
@@ -0,0 +1,21 @@
+//go:build js && wasm
+
+package main
+
+import "github.com/WalisNw/clock-in-out-client/internal/app"
+
+// LoadConfig sources configuration purely from CIO_* environment variables
+// on the web build; flag parsing against os.Args is not meaningful for a
+// page loaded in a browser, and there is no local filesystem to hold a
+// config file.
+func LoadConfig() (*app.Config, error) {
+	return &app.Config{
+		Host:       app.EnvOr("CIO_HOST", ""),
+		Port:       app.EnvOr("CIO_PORT", "443"),
+		ID:         app.EnvOr("CIO_ID", ""),
+		Insecure:   app.EnvOr("CIO_INSECURE", "") != "",
+		Fullscreen: app.EnvOr("CIO_FULLSCREEN", "") != "",
+		ServerName: app.EnvOr("CIO_SERVER_NAME", ""),
+		Secret:     app.EnvOr("CIO_SECRET", ""),
+	}, nil
+}
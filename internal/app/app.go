@@ -0,0 +1,552 @@
+// Package app holds the clock-in/out state machine shared by the Ebiten
+// frontend and the terminal (-tui) frontend: flag transitions, the gRPC
+// calls they trigger, and the offline queue. Frontends only translate their
+// own input events into calls on App, and render from App.View.
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strconv"
+	"sync"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/types/known/timestamppb"
+
+	"github.com/WalisNw/clock-in-out-client/internal/auth"
+	pb "github.com/WalisNw/clock-in-out-client/proto"
+)
+
+const (
+	AlertInterval     = 480
+	CountDownInterval = 300
+	DateTimeLayout    = "2006/01/02 15:04:05"
+
+	MinRangeDays     = 1
+	MaxRangeDays     = 90
+	DefaultRangeDays = 7
+)
+
+// queryTypes is the cycle order Up/Down step through in the Record state.
+var queryTypes = []pb.QueryType{pb.QueryType_DAY, pb.QueryType_LAST_SEVEN, pb.QueryType_RANGE}
+
+// ErrShutdown is returned by Advance/Confirm when the app should exit
+// normally (the CountDown screen timed out or the user confirmed it).
+var ErrShutdown = errors.New("shutdown")
+
+type State uint16
+
+const (
+	Connecting State = 1 << iota
+	Loading
+	Select
+	Clock
+	Record
+	Clocking
+	Querying
+	Queued
+	CountDown
+	Result
+	DatePicker
+)
+
+type Func uint8
+
+const (
+	FuncClock Func = 1 << iota
+	FuncQuery
+)
+
+// App is the shared clock-in/out state machine. Its exported fields are
+// also written by background goroutines (connect, streamQuery,
+// watchConnState, reconcileLoop) racing with a single frontend event loop's
+// calls to Advance/Confirm/Up/Down/Back/Scroll; mu guards all of them. A
+// frontend should not read the fields directly -- call View once per frame
+// and render from the returned snapshot instead.
+type App struct {
+	mu     sync.Mutex
+	cfg    Config
+	GRPC   *GRPC
+	Queue  *InputLog
+	closed bool // set by Close; connect checks it before installing a late connection
+
+	State        State
+	FuncFlag     Func
+	ClockType    pb.ClockType
+	QueryType    pb.QueryType
+	Records      []*pb.Record
+	Alert        string
+	Msg          string
+	Counter      uint16
+	Tick         uint
+	PendingNonce int64
+
+	// ConnState is kept up to date by a background goroutine watching the
+	// gRPC connection, for a frontend's connection-state indicator. It is
+	// connectivity.Shutdown before the first successful dial.
+	ConnState connectivity.State
+
+	RangeDays    int
+	ScrollOffset int
+	Streaming    bool
+}
+
+// View is a point-in-time, race-free snapshot of App's render-relevant
+// state. Frontends should call App.View once per frame and render from the
+// returned value rather than reading App's fields directly.
+type View struct {
+	State        State
+	FuncFlag     Func
+	ClockType    pb.ClockType
+	QueryType    pb.QueryType
+	Records      []*pb.Record
+	Alert        string
+	Msg          string
+	Counter      uint16
+	Tick         uint
+	ConnState    connectivity.State
+	RangeDays    int
+	ScrollOffset int
+	Streaming    bool
+	QueuePending int
+}
+
+// RangeStart returns the start of the selected query range, based on
+// RangeDays, as of when the View was taken.
+func (v View) RangeStart() time.Time {
+	return time.Now().AddDate(0, 0, -v.RangeDays)
+}
+
+// View returns a snapshot of the fields a frontend renders, safe to read
+// without further synchronization.
+func (a *App) View() View {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	v := View{
+		State:        a.State,
+		FuncFlag:     a.FuncFlag,
+		ClockType:    a.ClockType,
+		QueryType:    a.QueryType,
+		Records:      a.Records,
+		Alert:        a.Alert,
+		Msg:          a.Msg,
+		Counter:      a.Counter,
+		Tick:         a.Tick,
+		ConnState:    a.ConnState,
+		RangeDays:    a.RangeDays,
+		ScrollOffset: a.ScrollOffset,
+		Streaming:    a.Streaming,
+	}
+	// Queue.Pending() walks the whole offline queue under its own lock;
+	// only the Queued screen renders it, so skip the work on every other
+	// frame.
+	if a.State == Queued {
+		v.QueuePending = len(a.Queue.Pending())
+	}
+	return v
+}
+
+// client returns the current gRPC client, or nil before the first
+// successful dial. It exists so goroutines reading a.GRPC.Client (set once
+// connect succeeds) don't race with connect writing it.
+func (a *App) client() pb.ClockServiceClient {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	if a.GRPC == nil {
+		return nil
+	}
+	return a.GRPC.Client
+}
+
+// New opens the offline queue and returns an App in its initial Connecting
+// state. The returned App begins dialing the server on the first call to
+// Advance, and replays any queued punches before it reaches Select.
+func New(cfg Config) *App {
+	queue, err := OpenInputLog()
+	if err != nil {
+		log.Printf("Failed to open offline queue, punches will not survive a restart. err: %v", err)
+		queue = &InputLog{}
+	}
+	g := &GRPC{}
+	if cfg.Secret != "" {
+		g.Signer = auth.NewSigner(cfg.Secret)
+	}
+	a := &App{cfg: cfg, GRPC: g, Queue: queue, State: Connecting, RangeDays: DefaultRangeDays, ConnState: connectivity.Shutdown}
+	go a.reconcileLoop()
+	return a
+}
+
+// Close releases the underlying gRPC connection, if any, and tells a
+// connect already in flight to close the connection it is about to
+// install rather than leaking it.
+func (a *App) Close() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.closed = true
+	a.GRPC.Close()
+}
+
+// Advance runs the time- and network-driven part of the state machine. It
+// must be called once per frontend tick (Ebiten frame, TUI timer message).
+func (a *App) Advance() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch a.State {
+	case Connecting:
+		a.State = Loading
+		go a.connect()
+	case Loading:
+		a.Msg = "連線中"
+	case Clocking, Querying:
+		a.Msg = "請稍候"
+	case Queued:
+		if confirmed, serverTime, ok := a.Queue.Confirmation(a.PendingNonce); ok && confirmed {
+			a.Msg = fmt.Sprintf("%v %v", "已同步", serverTime.Local().Format(DateTimeLayout))
+			a.State = CountDown
+			a.Tick = CountDownInterval
+		}
+	case CountDown:
+		if a.Tick == 0 {
+			return ErrShutdown
+		}
+	}
+	a.Counter++
+	a.Tick--
+	if a.Tick == 0 {
+		a.Alert = ""
+	}
+	return nil
+}
+
+// Confirm handles the user's "confirm" action: Enter or the right arrow in
+// both frontends (Enter only on the Queued and CountDown screens).
+func (a *App) Confirm() error {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch a.State {
+	case Select:
+		switch a.FuncFlag {
+		case FuncClock:
+			a.State = Clock
+		case FuncQuery:
+			a.State = Record
+		}
+	case Clock:
+		a.queuePunchLocked()
+	case Record:
+		if a.QueryType == pb.QueryType_RANGE {
+			a.State = DatePicker
+		} else {
+			a.State = Querying
+			go a.streamQuery()
+		}
+	case DatePicker:
+		a.State = Querying
+		go a.streamQuery()
+	case Queued:
+		a.State = Select
+	case CountDown:
+		return ErrShutdown
+	}
+	return nil
+}
+
+// Up handles the "previous option" action (arrow up).
+func (a *App) Up() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch a.State {
+	case Select:
+		a.FuncFlag = FuncClock
+	case Clock:
+		a.ClockType = pb.ClockType_CLOCK_IN
+	case Record:
+		a.cycleQueryTypeLocked(-1)
+	case DatePicker:
+		a.adjustRangeDaysLocked(1)
+	case Result:
+		a.scrollLocked(-1)
+	}
+}
+
+// Down handles the "next option" action (arrow down).
+func (a *App) Down() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch a.State {
+	case Select:
+		a.FuncFlag = FuncQuery
+	case Clock:
+		a.ClockType = pb.ClockType_CLOCK_OUT
+	case Record:
+		a.cycleQueryTypeLocked(1)
+	case DatePicker:
+		a.adjustRangeDaysLocked(-1)
+	case Result:
+		a.scrollLocked(1)
+	}
+}
+
+// Back handles the "go back" action (arrow left).
+func (a *App) Back() {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	switch a.State {
+	case Clock, Record:
+		a.State = Select
+	case Result:
+		a.State = Record
+	case DatePicker:
+		a.State = Record
+	}
+}
+
+// Scroll moves the Result view by delta rows, clamped to the record list.
+// It is a no-op outside the Result state.
+func (a *App) Scroll(delta int) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.scrollLocked(delta)
+}
+
+func (a *App) scrollLocked(delta int) {
+	if a.State != Result {
+		return
+	}
+	a.ScrollOffset += delta
+	if a.ScrollOffset < 0 {
+		a.ScrollOffset = 0
+	}
+	if max := len(a.Records) - 1; max < 0 {
+		a.ScrollOffset = 0
+	} else if a.ScrollOffset > max {
+		a.ScrollOffset = max
+	}
+}
+
+// RangeStart returns the start of the selected query range, based on
+// RangeDays.
+func (a *App) RangeStart() time.Time {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	return time.Now().AddDate(0, 0, -a.RangeDays)
+}
+
+func (a *App) cycleQueryTypeLocked(delta int) {
+	idx := 0
+	for i, t := range queryTypes {
+		if t == a.QueryType {
+			idx = i
+			break
+		}
+	}
+	idx = (idx + delta + len(queryTypes)) % len(queryTypes)
+	a.QueryType = queryTypes[idx]
+}
+
+func (a *App) adjustRangeDaysLocked(delta int) {
+	a.RangeDays += delta
+	if a.RangeDays < MinRangeDays {
+		a.RangeDays = MinRangeDays
+	}
+	if a.RangeDays > MaxRangeDays {
+		a.RangeDays = MaxRangeDays
+	}
+}
+
+// connect dials the server in a background reconnection loop, backing off
+// exponentially (with jitter, to avoid a thundering herd against a shared
+// server recovering from an outage) between attempts. It only returns once
+// a connection is established or the App is closed; Advance stays in
+// Loading until then.
+func (a *App) connect() {
+	fmt.Println("connecting...")
+	backoff := time.Second
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		conn, err := dial(ctx, a.cfg, a.GRPC.Signer)
+		cancel()
+		if err == nil {
+			a.mu.Lock()
+			if a.closed {
+				a.mu.Unlock()
+				_ = conn.Close()
+				return
+			}
+			a.GRPC.Conn = conn
+			a.GRPC.Client = pb.NewClockServiceClient(conn)
+			a.mu.Unlock()
+			go a.watchConnState(conn)
+			a.drainQueue()
+			a.mu.Lock()
+			a.State = Select
+			a.FuncFlag = FuncClock
+			a.mu.Unlock()
+			return
+		}
+		log.Printf("Failed to connect, retrying. err: %v", err)
+		a.mu.Lock()
+		if a.closed {
+			a.mu.Unlock()
+			return
+		}
+		a.Alert = "連線異常，重試中"
+		a.Tick = AlertInterval
+		a.mu.Unlock()
+		time.Sleep(jitter(backoff))
+		backoff = nextDialBackoff(backoff)
+	}
+}
+
+// watchConnState keeps ConnState in sync with conn for its lifetime, so a
+// frontend can draw a connection-state indicator without polling
+// conn.GetState() itself every frame. It takes conn directly, rather than
+// reading a.GRPC.Conn, so it never races with a future reconnect replacing
+// that field.
+func (a *App) watchConnState(conn *grpc.ClientConn) {
+	ctx := context.Background()
+	for {
+		state := conn.GetState()
+		a.mu.Lock()
+		a.ConnState = state
+		a.mu.Unlock()
+		if !conn.WaitForStateChange(ctx, state) {
+			return
+		}
+	}
+}
+
+// queuePunchLocked assumes the caller already holds a.mu (it is only
+// called from Confirm).
+func (a *App) queuePunchLocked() {
+	id, _ := strconv.Atoi(a.cfg.ID)
+	entry := &QueueEntry{
+		Nonce:    time.Now().UnixNano(),
+		Time:     time.Now(),
+		MemberID: int32(id),
+		Type:     a.ClockType,
+	}
+	if err := a.Queue.Append(entry); err != nil {
+		log.Printf("Failed to persist queued punch. err: %v", err)
+	}
+	a.PendingNonce = entry.Nonce
+	a.State = Queued
+	go a.drainQueue()
+}
+
+// streamQuery drains QueryStream into Records as they arrive, so a long
+// history does not block the UI behind one large unary response. Records
+// is reset to nil as soon as the stream opens and the Result state is
+// scrollable immediately; Streaming stays true until the stream drains.
+//
+// A server that is merely Unavailable does not bounce the UI back to
+// Select: the request is retried with backoff and jitter in place, showing
+// a "重試中" alert while it retries.
+func (a *App) streamQuery() {
+	a.mu.Lock()
+	queryType := a.QueryType
+	a.mu.Unlock()
+
+	id, _ := strconv.Atoi(a.cfg.ID)
+	req := &pb.QueryRequest{Member: &pb.Member{Id: int32(id)}, Type: queryType}
+	if queryType == pb.QueryType_RANGE {
+		req.Start = timestamppb.New(a.RangeStart())
+		req.End = timestamppb.New(time.Now())
+	}
+	backoff := time.Second
+	for {
+		ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
+		if a.GRPC.Signer != nil {
+			ctx = auth.SignQueryRequest(ctx, a.GRPC.Signer, req)
+		}
+		stream, err := a.client().QueryStream(ctx, req)
+		if err != nil {
+			cancel()
+			if status.Code(err) == codes.Unavailable {
+				a.mu.Lock()
+				a.Alert = "重試中"
+				a.Tick = AlertInterval
+				a.mu.Unlock()
+				time.Sleep(jitter(backoff))
+				backoff = nextDialBackoff(backoff)
+				continue
+			}
+			log.Printf("Failed to query records. err: %v", err)
+			a.mu.Lock()
+			a.Alert = "查詢失敗"
+			a.Tick = AlertInterval
+			a.State = Select
+			a.mu.Unlock()
+			return
+		}
+		a.mu.Lock()
+		a.Records = nil
+		a.ScrollOffset = 0
+		a.Streaming = true
+		a.State = Result
+		a.mu.Unlock()
+		for {
+			rec, err := stream.Recv()
+			if errors.Is(err, io.EOF) {
+				break
+			}
+			if err != nil {
+				log.Printf("Record stream ended early. err: %v", err)
+				break
+			}
+			a.mu.Lock()
+			a.Records = append(a.Records, rec)
+			a.mu.Unlock()
+		}
+		cancel()
+		a.mu.Lock()
+		a.Streaming = false
+		a.mu.Unlock()
+		return
+	}
+}
+
+// reconcileLoop periodically drains the offline queue for the lifetime of
+// the process, so punches recorded while disconnected are submitted as
+// soon as connectivity allows. The interval is jittered so that many
+// clients reconnecting to the same server after an outage do not all
+// retry in lockstep.
+func (a *App) reconcileLoop() {
+	for {
+		time.Sleep(jitter(3 * time.Second))
+		a.drainQueue()
+	}
+}
+
+// drainQueue submits every unconfirmed queued entry, in order, deduplicating
+// via nonce. It is a no-op when there is no live connection, and safe to
+// call repeatedly or concurrently from the reconcile loop and the UI. A
+// transient Unavailable simply leaves the entry queued for the next pass,
+// rather than being logged as a failure.
+func (a *App) drainQueue() {
+	client := a.client()
+	if client == nil {
+		return
+	}
+	for _, e := range a.Queue.Pending() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		res, err := client.Clock(ctx, &pb.ClockRequest{Member: &pb.Member{Id: e.MemberID}, Type: e.Type, Nonce: e.Nonce})
+		cancel()
+		if err != nil {
+			if status.Code(err) != codes.Unavailable {
+				log.Printf("Failed to replay queued punch (nonce=%d). err: %v", e.Nonce, err)
+			}
+			return
+		}
+		if err := a.Queue.Confirm(e.Nonce, res.Time.AsTime()); err != nil {
+			log.Printf("Failed to persist confirmation for queued punch (nonce=%d). err: %v", e.Nonce, err)
+		}
+	}
+}
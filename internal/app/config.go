@@ -0,0 +1,27 @@
+package app
+
+import "os"
+
+// Config holds the runtime settings needed to reach the clock server and
+// identify this kiosk. It replaces the link-time -ldflags -X variables so
+// a single binary can be repointed at staging/production without
+// rebuilding.
+type Config struct {
+	Host       string `yaml:"host"`
+	Port       string `yaml:"port"`
+	ID         string `yaml:"id"`
+	Insecure   bool   `yaml:"insecure"`
+	Fullscreen bool   `yaml:"fullscreen"`
+	ServerName string `yaml:"server_name"`
+	Secret     string `yaml:"secret"`
+	TUI        bool   `yaml:"-"`
+}
+
+// EnvOr returns the value of the environment variable key, or fallback if
+// it is unset.
+func EnvOr(key, fallback string) string {
+	if v, ok := os.LookupEnv(key); ok {
+		return v
+	}
+	return fallback
+}
@@ -0,0 +1,118 @@
+package app
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"math/rand"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/connectivity"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/keepalive"
+
+	"github.com/WalisNw/clock-in-out-client/internal/auth"
+	pb "github.com/WalisNw/clock-in-out-client/proto"
+)
+
+// retryServiceConfig makes Clock/Query/QueryStream retry transient failures
+// (in particular UNAVAILABLE, the status a restarting or unreachable server
+// returns) with exponential backoff, instead of surfacing every blip to the
+// caller.
+const retryServiceConfig = `{
+	"methodConfig": [{
+		"name": [
+			{"service": "pb.ClockService", "method": "Clock"},
+			{"service": "pb.ClockService", "method": "Query"},
+			{"service": "pb.ClockService", "method": "QueryStream"}
+		],
+		"retryPolicy": {
+			"MaxAttempts": 5,
+			"InitialBackoff": "0.5s",
+			"MaxBackoff": "10s",
+			"BackoffMultiplier": 2.0,
+			"RetryableStatusCodes": ["UNAVAILABLE"]
+		}
+	}]
+}`
+
+// maxDialBackoff caps the delay between reconnect attempts in connect's
+// background loop.
+const maxDialBackoff = 30 * time.Second
+
+// GRPC wraps the connection to the clock server shared by both frontends.
+type GRPC struct {
+	Conn   *grpc.ClientConn
+	Client pb.ClockServiceClient
+
+	// Signer is non-nil when cfg.Secret is set.
+	Signer *auth.Signer
+}
+
+// State reports the current connectivity state, for a frontend's
+// connection-state indicator. It is connectivity.Shutdown before the first
+// successful dial.
+func (g *GRPC) State() connectivity.State {
+	if g.Conn == nil {
+		return connectivity.Shutdown
+	}
+	return g.Conn.GetState()
+}
+
+// Close closes the underlying connection, if one was established.
+func (g *GRPC) Close() {
+	if g.Conn != nil {
+		_ = g.Conn.Close()
+	}
+}
+
+func dial(ctx context.Context, cfg Config, signer *auth.Signer) (*grpc.ClientConn, error) {
+	var opts []grpc.DialOption
+	if cfg.Insecure {
+		opts = append(opts, grpc.WithInsecure())
+	} else {
+		opts = append(opts, grpc.WithAuthority(cfg.Host))
+		serverName := cfg.ServerName
+		if serverName == "" {
+			serverName = cfg.Host
+		}
+		cred := credentials.NewTLS(&tls.Config{
+			ServerName:         serverName,
+			InsecureSkipVerify: false,
+		})
+		opts = append(opts, grpc.WithTransportCredentials(cred))
+	}
+	opts = append(opts,
+		grpc.WithKeepaliveParams(keepalive.ClientParameters{
+			Time:                20 * time.Second,
+			Timeout:             5 * time.Second,
+			PermitWithoutStream: true,
+		}),
+		grpc.WithDefaultServiceConfig(retryServiceConfig),
+		// WithBlock makes DialContext wait for a connection (or ctx's
+		// deadline) instead of returning immediately; connect relies
+		// on a non-nil error here to know a dial actually failed
+		// and that its retry loop should keep backing off.
+		grpc.WithBlock(),
+	)
+	if signer != nil {
+		opts = append(opts, grpc.WithChainUnaryInterceptor(auth.UnaryClientInterceptor(signer)))
+	}
+	return grpc.DialContext(ctx, fmt.Sprintf("%s:%s", cfg.Host, cfg.Port), opts...)
+}
+
+// jitter returns a duration in [d/2, d], so that many clients backing off
+// from the same outage do not all redial in lockstep.
+func jitter(d time.Duration) time.Duration {
+	return d/2 + time.Duration(rand.Int63n(int64(d)/2+1))
+}
+
+// nextDialBackoff doubles d, capped at maxDialBackoff.
+func nextDialBackoff(d time.Duration) time.Duration {
+	d *= 2
+	if d > maxDialBackoff {
+		d = maxDialBackoff
+	}
+	return d
+}
@@ -0,0 +1,151 @@
+package app
+
+import (
+	"bufio"
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	pb "github.com/WalisNw/clock-in-out-client/proto"
+)
+
+// QueueEntry is one durable record of a clock-in/out action. Every punch is
+// recorded here first, whether or not the server is reachable. Entries are
+// replayed in the order they
+// were recorded once connectivity returns and are deduplicated by Nonce.
+type QueueEntry struct {
+	Nonce      int64        `json:"nonce"`
+	Time       time.Time    `json:"time"`
+	MemberID   int32        `json:"member_id"`
+	Type       pb.ClockType `json:"type"`
+	Confirmed  bool         `json:"confirmed"`
+	ServerTime *time.Time   `json:"server_time,omitempty"`
+}
+
+// InputLog is a log of QueueEntry records persisted as JSON lines under
+// the user config dir, so unsubmitted punches survive a crash or shutdown.
+// Entries are never removed, but the file is not appended to: Append and
+// Confirm rewrite the whole file, and Confirm updates the confirmed entry
+// in place.
+type InputLog struct {
+	mu      sync.Mutex
+	path    string
+	entries []*QueueEntry
+}
+
+func queueLogPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	dir = filepath.Join(dir, "clock-in-out-client")
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "queue.jsonl"), nil
+}
+
+// OpenInputLog loads any entries persisted from a previous run, creating
+// the log file if it does not yet exist.
+func OpenInputLog() (*InputLog, error) {
+	path, err := queueLogPath()
+	if err != nil {
+		return nil, err
+	}
+	l := &InputLog{path: path}
+	f, err := os.OpenFile(path, os.O_RDONLY|os.O_CREATE, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := scanner.Bytes()
+		if len(line) == 0 {
+			continue
+		}
+		var e QueueEntry
+		if err := json.Unmarshal(line, &e); err != nil {
+			log.Printf("Failed to parse queued entry, skipping. err: %v", err)
+			continue
+		}
+		l.entries = append(l.entries, &e)
+	}
+	return l, scanner.Err()
+}
+
+// Append records a new entry and persists the log.
+func (l *InputLog) Append(e *QueueEntry) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.entries = append(l.entries, e)
+	return l.writeLocked()
+}
+
+// Pending returns the unconfirmed entries, in the order they were recorded.
+func (l *InputLog) Pending() []*QueueEntry {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	var pending []*QueueEntry
+	for _, e := range l.entries {
+		if !e.Confirmed {
+			pending = append(pending, e)
+		}
+	}
+	return pending
+}
+
+// Confirmation reports whether the entry with the given nonce exists and,
+// if so, whether it has been confirmed and its server timestamp. It reads
+// both fields under l.mu rather than returning a pointer into the log, so
+// callers never observe Confirm's two writes (Confirmed, then ServerTime)
+// torn across goroutines.
+func (l *InputLog) Confirmation(nonce int64) (confirmed bool, serverTime time.Time, ok bool) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if e.Nonce == nonce {
+			if e.Confirmed && e.ServerTime != nil {
+				return true, *e.ServerTime, true
+			}
+			return false, time.Time{}, true
+		}
+	}
+	return false, time.Time{}, false
+}
+
+// Confirm marks the entry with the given nonce as confirmed by the server
+// and records its authoritative timestamp.
+func (l *InputLog) Confirm(nonce int64, serverTime time.Time) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for _, e := range l.entries {
+		if e.Nonce == nonce {
+			e.Confirmed = true
+			e.ServerTime = &serverTime
+			break
+		}
+	}
+	return l.writeLocked()
+}
+
+func (l *InputLog) writeLocked() error {
+	if l.path == "" {
+		return nil
+	}
+	f, err := os.Create(l.path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	enc := json.NewEncoder(f)
+	for _, e := range l.entries {
+		if err := enc.Encode(e); err != nil {
+			return err
+		}
+	}
+	return nil
+}
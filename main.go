@@ -0,0 +1,19 @@
+package main
+
+import (
+	"log"
+	"os"
+)
+
+func main() {
+	cfg, err := LoadConfig()
+	if err != nil {
+		log.Printf("Failed to load config. err: %v", err)
+		os.Exit(1)
+	}
+	if cfg.TUI {
+		runTUI(cfg)
+		return
+	}
+	runEbiten(cfg)
+}
@@ -0,0 +1,189 @@
+//go:build !js || !wasm
+
+package main
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/grpc/connectivity"
+
+	"github.com/WalisNw/clock-in-out-client/internal/app"
+	pb "github.com/WalisNw/clock-in-out-client/proto"
+)
+
+var (
+	tuiTitleStyle    = lipgloss.NewStyle().Bold(true)
+	tuiAlertStyle    = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	tuiSelectedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("2")).Bold(true)
+	tuiHeaderStyle   = lipgloss.NewStyle().Bold(true).Underline(true)
+	tuiFooterStyle   = lipgloss.NewStyle().Faint(true)
+
+	tuiConnReady   = lipgloss.NewStyle().Foreground(lipgloss.Color("2"))
+	tuiConnPending = lipgloss.NewStyle().Foreground(lipgloss.Color("3"))
+	tuiConnDown    = lipgloss.NewStyle().Foreground(lipgloss.Color("1"))
+)
+
+type tuiTickMsg time.Time
+
+func tuiTick() tea.Cmd {
+	return tea.Tick(time.Second/60, func(t time.Time) tea.Msg {
+		return tuiTickMsg(t)
+	})
+}
+
+// tuiModel is the terminal frontend, used over SSH or on headless kiosks
+// that cannot run the Ebiten window. Like Game, it only translates input
+// into calls on app.App and renders its exported state.
+type tuiModel struct {
+	app *app.App
+}
+
+func runTUI(cfg *app.Config) {
+	a := app.New(*cfg)
+	defer a.Close()
+	if _, err := tea.NewProgram(tuiModel{app: a}).Run(); err != nil {
+		log.Printf("an error occurred: %v", err)
+	}
+}
+
+func (m tuiModel) Init() tea.Cmd {
+	return tuiTick()
+}
+
+func (m tuiModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if quit := m.handleKey(msg); quit {
+			return m, tea.Quit
+		}
+	case tuiTickMsg:
+		if err := m.app.Advance(); err != nil {
+			if !errors.Is(err, app.ErrShutdown) {
+				log.Printf("an error occurred: %v", err)
+			}
+			return m, tea.Quit
+		}
+		return m, tuiTick()
+	}
+	return m, nil
+}
+
+// handleKey applies a key press to the app and reports whether the program
+// should exit.
+func (m tuiModel) handleKey(msg tea.KeyMsg) bool {
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return true
+	}
+	var err error
+	switch m.app.View().State {
+	case app.Select, app.Clock, app.Record, app.DatePicker:
+		switch msg.String() {
+		case "enter", "right":
+			err = m.app.Confirm()
+		case "up":
+			m.app.Up()
+		case "down":
+			m.app.Down()
+		case "left":
+			m.app.Back()
+		}
+	case app.Queued, app.CountDown:
+		if msg.String() == "enter" {
+			err = m.app.Confirm()
+		}
+	case app.Result:
+		switch msg.String() {
+		case "left":
+			m.app.Back()
+		case "up":
+			m.app.Scroll(-1)
+		case "down":
+			m.app.Scroll(1)
+		case "pgup":
+			m.app.Scroll(-tuiResultVisibleRows)
+		case "pgdown":
+			m.app.Scroll(tuiResultVisibleRows)
+		}
+	}
+	return err != nil
+}
+
+// tuiResultVisibleRows is the terminal analogue of the Ebiten frontend's
+// resultVisibleRows: a fixed page size, since a terminal has no pixel
+// layout to derive it from.
+const tuiResultVisibleRows = 10
+
+func (m tuiModel) View() string {
+	a := m.app.View()
+	var b strings.Builder
+	fmt.Fprintf(&b, "現在時間: %s %s\n", time.Now().Format(app.DateTimeLayout), tuiConnDot(a.ConnState))
+	if a.Alert != "" {
+		b.WriteString(tuiAlertStyle.Render(a.Alert) + "\n")
+	}
+	switch a.State {
+	case app.Select:
+		b.WriteString(tuiTitleStyle.Render("請選擇:") + "\n")
+		b.WriteString(tuiOption("打卡", a.FuncFlag == app.FuncClock))
+		b.WriteString(tuiOption("查詢", a.FuncFlag == app.FuncQuery))
+	case app.Clock:
+		b.WriteString(tuiTitleStyle.Render("請選擇:") + "\n")
+		b.WriteString(tuiOption("上班打卡", a.ClockType == pb.ClockType_CLOCK_IN))
+		b.WriteString(tuiOption("下班打卡", a.ClockType == pb.ClockType_CLOCK_OUT))
+	case app.Record:
+		b.WriteString(tuiTitleStyle.Render("請選擇:") + "\n")
+		b.WriteString(tuiOption("本日", a.QueryType == pb.QueryType_DAY))
+		b.WriteString(tuiOption("前七日", a.QueryType == pb.QueryType_LAST_SEVEN))
+		b.WriteString(tuiOption("自訂區間", a.QueryType == pb.QueryType_RANGE))
+	case app.DatePicker:
+		b.WriteString(tuiTitleStyle.Render("請選擇查詢區間:") + "\n")
+		fmt.Fprintf(&b, "最近 %d 天 (%s ~ %s)\n", a.RangeDays, a.RangeStart().Format("2006/01/02"), time.Now().Format("2006/01/02"))
+	case app.Loading, app.Clocking, app.Querying:
+		b.WriteString(a.Msg + strings.Repeat(".", int(a.Counter)%180/30) + "\n")
+	case app.Queued:
+		b.WriteString("已記錄，待同步" + strings.Repeat(".", int(a.Counter)%180/30) + "\n")
+		if a.QueuePending > 1 {
+			fmt.Fprintf(&b, "尚有 %d 筆等待同步\n", a.QueuePending)
+		}
+	case app.CountDown:
+		b.WriteString(a.Msg + "\n")
+		fmt.Fprintf(&b, "將於 %d 秒後自動關閉或按 Enter 直接關閉\n", (a.Tick/60)+1)
+	case app.Result:
+		b.WriteString(tuiHeaderStyle.Render(fmt.Sprintf("%-12s%-10s%-10s", "日期", "上班", "下班")) + "\n")
+		for i := 0; i < tuiResultVisibleRows && a.ScrollOffset+i < len(a.Records); i++ {
+			r := a.Records[a.ScrollOffset+i]
+			fmt.Fprintf(&b, "%-12s%-10s%-10s\n", r.Date, r.In, r.Out)
+		}
+		if a.Streaming {
+			b.WriteString("載入中...\n")
+		}
+	}
+	b.WriteString(tuiFooterStyle.Render("↑/↓ 切換　Enter 確認　← 返回　q 離開") + "\n")
+	return b.String()
+}
+
+// tuiConnDot renders the same green/yellow/red connection-state indicator
+// as the Ebiten frontend's dot next to the clock.
+func tuiConnDot(s connectivity.State) string {
+	switch s {
+	case connectivity.Ready:
+		return tuiConnReady.Render("●")
+	case connectivity.Idle, connectivity.Connecting:
+		return tuiConnPending.Render("●")
+	default:
+		return tuiConnDown.Render("●")
+	}
+}
+
+func tuiOption(label string, selected bool) string {
+	if selected {
+		return tuiSelectedStyle.Render("> "+label) + "\n"
+	}
+	return "  " + label + "\n"
+}
@@ -0,0 +1,15 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"log"
+
+	"github.com/WalisNw/clock-in-out-client/internal/app"
+)
+
+// runTUI is unavailable on the web build: there is no terminal to draw
+// into inside a browser tab.
+func runTUI(cfg *app.Config) {
+	log.Printf("-tui is not supported in the web build")
+}